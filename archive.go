@@ -0,0 +1,258 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat selects which archive codec dirTreeFromArchive reads.
+type ArchiveFormat int
+
+const (
+	// ArchiveTar reads a tar stream (optionally gzip-decompressed by the caller).
+	ArchiveTar ArchiveFormat = iota
+	// ArchiveZip reads a zip stream.
+	ArchiveZip
+)
+
+// archiveEntry is one flattened header read out of a tar or zip stream.
+type archiveEntry struct {
+	name    string // slash-separated path within the archive
+	size    int64
+	isDir   bool
+	modTime time.Time
+}
+
+// dirTreeFromArchive reads a tar or zip stream from r and renders it through
+// formatter, the same shape dirTree produces for a real filesystem, without
+// ever extracting the archive to disk.
+func dirTreeFromArchive(out io.Writer, r io.Reader, format ArchiveFormat, opts Options, formatter Formatter) error {
+	entries, err := readArchiveEntries(r, format)
+	if err != nil {
+		return err
+	}
+
+	nodes, dirCount, fileCount := buildArchiveTree(entries, opts)
+
+	if err := formatter.Format(out, nodes); err != nil {
+		return err
+	}
+
+	if opts.NoReport || isMtreeOutput(formatter) {
+		return nil
+	}
+
+	_, err = fmt.Fprintf(out, "\n%d directories, %d files\n", dirCount, fileCount)
+	return err
+}
+
+func readArchiveEntries(r io.Reader, format ArchiveFormat) ([]archiveEntry, error) {
+	switch format {
+	case ArchiveTar:
+		return readTarEntries(r)
+	case ArchiveZip:
+		return readZipEntries(r)
+	default:
+		return nil, fmt.Errorf("unknown archive format %v", format)
+	}
+}
+
+func readTarEntries(r io.Reader) ([]archiveEntry, error) {
+	var entries []archiveEntry
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := normalizeArchiveName(header.Name)
+		if name == "" {
+			continue
+		}
+		entries = append(entries, archiveEntry{
+			name:    name,
+			size:    header.Size,
+			isDir:   header.Typeflag == tar.TypeDir,
+			modTime: header.ModTime,
+		})
+	}
+
+	return entries, nil
+}
+
+// readZipEntries reads the whole stream into memory first: the zip central
+// directory lives at the end of the file, so unlike tar it cannot be parsed
+// from a plain io.Reader without seeking.
+func readZipEntries(r io.Reader) ([]archiveEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]archiveEntry, 0, len(zr.File))
+	for _, file := range zr.File {
+		name := normalizeArchiveName(file.Name)
+		if name == "" {
+			continue
+		}
+		entries = append(entries, archiveEntry{
+			name:    name,
+			size:    int64(file.UncompressedSize64),
+			isDir:   file.FileInfo().IsDir(),
+			modTime: file.Modified,
+		})
+	}
+
+	return entries, nil
+}
+
+// normalizeArchiveName strips a tar/zip header name down to a clean,
+// slash-separated relative path, collapsing "./" root entries to "".
+func normalizeArchiveName(name string) string {
+	name = strings.TrimSuffix(name, "/")
+	name = strings.TrimPrefix(name, "./")
+	name = strings.TrimPrefix(name, "/")
+	if name == "." {
+		return ""
+	}
+	return name
+}
+
+// archiveNode is a directory or file synthesized while building the tree
+// implied by a flat list of archive headers: intermediate path segments
+// that have no header of their own still need a DirInfo node.
+type archiveNode struct {
+	name     string
+	isDir    bool
+	size     int64
+	modTime  time.Time
+	children map[string]*archiveNode
+	order    []string
+}
+
+func newArchiveNode(name string) *archiveNode {
+	return &archiveNode{name: name, children: map[string]*archiveNode{}}
+}
+
+// buildArchiveTree turns a flat list of archive entries into the same
+// []Node/DirInfo/FileInfo shape readDir produces, aggregating implied
+// directories along the way, then applies opts' filters and sort order.
+func buildArchiveTree(entries []archiveEntry, opts Options) (nodes []Node, dirCount int, fileCount int) {
+	root := newArchiveNode("")
+	root.isDir = true
+
+	for _, entry := range entries {
+		segments := strings.Split(strings.Trim(entry.name, "/"), "/")
+		cur := root
+		for i, segment := range segments {
+			if segment == "" {
+				continue
+			}
+			child, ok := cur.children[segment]
+			if !ok {
+				child = newArchiveNode(segment)
+				cur.children[segment] = child
+				cur.order = append(cur.order, segment)
+			}
+			if i == len(segments)-1 {
+				child.isDir = entry.isDir
+				child.size = entry.size
+				child.modTime = entry.modTime
+			} else {
+				child.isDir = true
+			}
+			cur = child
+		}
+	}
+
+	return archiveNodeChildren(root, opts)
+}
+
+func archiveNodeChildren(node *archiveNode, opts Options) (nodes []Node, dirCount int, fileCount int) {
+	children := make([]*archiveNode, 0, len(node.order))
+	for _, name := range node.order {
+		children = append(children, node.children[name])
+	}
+	children = filterArchiveNodes(children, opts)
+	sortArchiveNodes(children, opts)
+
+	for _, child := range children {
+		if child.isDir {
+			grandchildren, childDirs, childFiles := archiveNodeChildren(child, opts)
+			nodes = append(nodes, DirInfo{name: child.name, children: grandchildren})
+			dirCount += 1 + childDirs
+			fileCount += childFiles
+		} else {
+			nodes = append(nodes, FileInfo{name: child.name, size: child.size})
+			fileCount++
+		}
+	}
+
+	return nodes, dirCount, fileCount
+}
+
+func filterArchiveNodes(children []*archiveNode, opts Options) []*archiveNode {
+	kept := children[:0]
+	for _, child := range children {
+		if !opts.All && strings.HasPrefix(child.name, ".") {
+			continue
+		}
+		if child.isDir {
+			if opts.Exclude != "" && matchesPattern(opts.Exclude, child.name) {
+				continue
+			}
+		} else {
+			if !opts.WithFiles || opts.DirsOnly {
+				continue
+			}
+			if opts.Exclude != "" && matchesPattern(opts.Exclude, child.name) {
+				continue
+			}
+			if opts.Include != "" && !matchesPattern(opts.Include, child.name) {
+				continue
+			}
+		}
+		kept = append(kept, child)
+	}
+	return kept
+}
+
+func sortArchiveNodes(children []*archiveNode, opts Options) {
+	less := func(i, j int) bool {
+		switch opts.SortBy {
+		case "size":
+			return children[i].size < children[j].size
+		case "mtime", "ctime":
+			return children[i].modTime.Before(children[j].modTime)
+		default:
+			return children[i].name < children[j].name
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if opts.Reverse {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+	if opts.DirsFirst {
+		sort.SliceStable(children, func(i, j int) bool {
+			return children[i].isDir && !children[j].isDir
+		})
+	}
+}
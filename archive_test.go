@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func buildTarStream(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data))}); err != nil {
+			t.Fatalf("WriteHeader(%q): %v", name, err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func buildZipStream(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadTarEntriesNormalizesDotSlashNames(t *testing.T) {
+	stream := buildTarStream(t, map[string]string{
+		"./":          "",
+		"./a/b.txt":   "hi",
+		"./a/c/d.txt": "there",
+	})
+
+	entries, err := readTarEntries(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readTarEntries: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.name)
+	}
+	for _, want := range []string{"a/b.txt", "a/c/d.txt"} {
+		found := false
+		for _, name := range names {
+			if name == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected a normalized %q entry, got %v", want, names)
+		}
+	}
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "./") {
+			t.Fatalf("expected the root './' entry to be dropped, got %v", names)
+		}
+	}
+}
+
+func TestReadZipEntriesNormalizesNames(t *testing.T) {
+	stream := buildZipStream(t, map[string]string{
+		"a/b.txt": "hi",
+	})
+
+	entries, err := readZipEntries(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readZipEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].name != "a/b.txt" || entries[0].size != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestBuildArchiveTreeSynthesizesImpliedDirectories(t *testing.T) {
+	stream := buildTarStream(t, map[string]string{
+		"a/b/c.txt": "hello",
+	})
+	entries, err := readTarEntries(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readTarEntries: %v", err)
+	}
+
+	nodes, dirCount, fileCount := buildArchiveTree(entries, defaultOptions())
+	if dirCount != 2 || fileCount != 1 {
+		t.Fatalf("expected 2 synthesized directories and 1 file, got dirs=%d files=%d", dirCount, fileCount)
+	}
+
+	top, ok := nodes[0].(DirInfo)
+	if !ok || top.Name() != "a" {
+		t.Fatalf("expected top-level synthesized directory %q, got %v", "a", nodes)
+	}
+	inner, ok := top.Children()[0].(DirInfo)
+	if !ok || inner.Name() != "b" {
+		t.Fatalf("expected synthesized directory %q, got %v", "b", top.Children())
+	}
+	if len(inner.Children()) != 1 || inner.Children()[0].Name() != "c.txt" {
+		t.Fatalf("expected c.txt under a/b, got %v", inner.Children())
+	}
+}
+
+func TestDirTreeFromArchiveHonorsFormatter(t *testing.T) {
+	stream := buildTarStream(t, map[string]string{
+		"a.txt": "hi",
+	})
+
+	var buf bytes.Buffer
+	if err := dirTreeFromArchive(&buf, bytes.NewReader(stream), ArchiveTar, defaultOptions(), JSONFormatter{}); err != nil {
+		t.Fatalf("dirTreeFromArchive: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"name": "a.txt"`) {
+		t.Fatalf("expected the archive tree to be rendered as JSON, got:\n%s", buf.String())
+	}
+}
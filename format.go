@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+)
+
+// xmlEscape escapes s so it's safe to embed as XML text or an attribute
+// value, unlike fmt's %q which escapes Go-string-style, not XML-style.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// Formatter renders a tree of Nodes to out in some output format.
+type Formatter interface {
+	Format(out io.Writer, nodes []Node) error
+}
+
+// formatterFor resolves the Formatter registered under name, mirroring the
+// -o flag's accepted values.
+func formatterFor(name string) (Formatter, error) {
+	switch name {
+	case "", "ascii":
+		return ASCIIFormatter{}, nil
+	case "json":
+		return JSONFormatter{}, nil
+	case "xml":
+		return XMLFormatter{}, nil
+	case "html":
+		return HTMLFormatter{}, nil
+	case "mtree":
+		return MtreeFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown -o value %q, want ascii|json|xml|html|mtree", name)
+	}
+}
+
+// isMtreeOutput reports whether formatter is MtreeFormatter. dirTree and
+// dirTreeFromArchive use it to skip the trailing "N directories, M files"
+// report line: that footer isn't a valid mtree entry, so appending it to an
+// mtree stream corrupts the manifest for a later Verify.
+func isMtreeOutput(formatter Formatter) bool {
+	_, ok := formatter.(MtreeFormatter)
+	return ok
+}
+
+// ASCIIFormatter renders the tree the way this tool has always printed it.
+type ASCIIFormatter struct{}
+
+// Format prints nodes using the box-drawing ASCII tree layout, via the same
+// renderASCII the live fs.FS walk uses.
+func (ASCIIFormatter) Format(out io.Writer, nodes []Node) error {
+	_, _, err := renderASCII(out, func(visit func(Event) error) error {
+		return nodeWalk(nodes, visit)
+	})
+	return err
+}
+
+// jsonNode is the de-facto schema emitted by `tree -J`.
+type jsonNode struct {
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Size     int64      `json:"size,omitempty"`
+	Contents []jsonNode `json:"contents,omitempty"`
+}
+
+func toJSONNodes(nodes []Node) []jsonNode {
+	result := make([]jsonNode, 0, len(nodes))
+	for _, node := range nodes {
+		if directory, ok := node.(DirInfo); ok {
+			result = append(result, jsonNode{
+				Type:     "directory",
+				Name:     directory.Name(),
+				Contents: toJSONNodes(directory.Children()),
+			})
+			continue
+		}
+		file := node.(FileInfo)
+		result = append(result, jsonNode{
+			Type: "file",
+			Name: file.Name(),
+			Size: file.Size(),
+		})
+	}
+	return result
+}
+
+// JSONFormatter renders the tree as an array of {"type","name","contents"}
+// objects matching `tree -J`'s schema.
+type JSONFormatter struct{}
+
+// Format writes nodes as indented JSON.
+func (JSONFormatter) Format(out io.Writer, nodes []Node) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toJSONNodes(nodes))
+}
+
+// XMLFormatter renders the tree as nested <directory>/<file> elements
+// wrapped in a <tree> root, matching `tree -X`'s shape.
+type XMLFormatter struct{}
+
+// Format writes nodes as indented XML.
+func (formatter XMLFormatter) Format(out io.Writer, nodes []Node) error {
+	if _, err := fmt.Fprintln(out, "<tree>"); err != nil {
+		return err
+	}
+	if err := formatter.writeNodes(out, nodes, "  "); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, "</tree>")
+	return err
+}
+
+func (formatter XMLFormatter) writeNodes(out io.Writer, nodes []Node, indent string) error {
+	for _, node := range nodes {
+		if directory, ok := node.(DirInfo); ok {
+			if _, err := fmt.Fprintf(out, "%s<directory name=\"%s\">\n", indent, xmlEscape(directory.Name())); err != nil {
+				return err
+			}
+			if err := formatter.writeNodes(out, directory.Children(), indent+"  "); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(out, "%s</directory>\n", indent); err != nil {
+				return err
+			}
+			continue
+		}
+		file := node.(FileInfo)
+		if _, err := fmt.Fprintf(out, "%s<file name=\"%s\" size=\"%d\"/>\n", indent, xmlEscape(file.Name()), file.Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTMLFormatter renders the tree as a nested, collapsible <ul> listing.
+type HTMLFormatter struct{}
+
+// Format writes nodes as a nested unordered HTML list.
+func (formatter HTMLFormatter) Format(out io.Writer, nodes []Node) error {
+	if _, err := fmt.Fprintln(out, "<ul>"); err != nil {
+		return err
+	}
+	if err := formatter.writeNodes(out, nodes); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, "</ul>")
+	return err
+}
+
+func (formatter HTMLFormatter) writeNodes(out io.Writer, nodes []Node) error {
+	for _, node := range nodes {
+		if directory, ok := node.(DirInfo); ok {
+			if _, err := fmt.Fprintf(out, "<li><details open><summary>%s</summary>\n<ul>\n", html.EscapeString(directory.Name())); err != nil {
+				return err
+			}
+			if err := formatter.writeNodes(out, directory.Children()); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(out, "</ul>\n</details></li>"); err != nil {
+				return err
+			}
+			continue
+		}
+		file := node.(FileInfo)
+		if _, err := fmt.Fprintf(out, "<li>%s</li>\n", html.EscapeString(file.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func sampleNodes() []Node {
+	return []Node{
+		DirInfo{name: "dir", children: []Node{
+			FileInfo{name: "inner.txt", size: 3},
+		}},
+		FileInfo{name: "top.txt", size: 0},
+	}
+}
+
+func TestASCIIFormatterDrawsBoxConnectors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (ASCIIFormatter{}).Format(&buf, sampleNodes()); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	want := "├───dir\n│\t└───inner.txt (3b)\n└───top.txt (empty)\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONFormatterMatchesTreeJSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONFormatter{}).Format(&buf, sampleNodes()); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	for _, want := range []string{`"type": "directory"`, `"name": "dir"`, `"type": "file"`, `"size": 3`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected JSON output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestXMLFormatterEscapesUnsafeNames(t *testing.T) {
+	nodes := []Node{
+		DirInfo{name: `weird&"<dir>`, children: []Node{
+			FileInfo{name: `weird&"<file>.txt`, size: 1},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := (XMLFormatter{}).Format(&buf, nodes); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "<dir>") || strings.Contains(out, "<file>") {
+		t.Fatalf("expected XML metacharacters in names to be escaped, got:\n%s", out)
+	}
+	if !strings.Contains(out, "weird&amp;&#34;&lt;dir&gt;") {
+		t.Fatalf("expected the directory name to be XML-escaped, got:\n%s", out)
+	}
+}
+
+func TestHTMLFormatterEscapesUnsafeNames(t *testing.T) {
+	nodes := []Node{
+		FileInfo{name: "<script>alert(1)</script>", size: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := (HTMLFormatter{}).Format(&buf, nodes); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "<script>") {
+		t.Fatalf("expected the file name to be HTML-escaped, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "&lt;script&gt;") {
+		t.Fatalf("expected an escaped &lt;script&gt; tag, got:\n%s", buf.String())
+	}
+}
+
+func TestFormatterForResolvesFlagValues(t *testing.T) {
+	cases := map[string]Formatter{
+		"":      ASCIIFormatter{},
+		"ascii": ASCIIFormatter{},
+		"json":  JSONFormatter{},
+		"xml":   XMLFormatter{},
+		"html":  HTMLFormatter{},
+		"mtree": MtreeFormatter{},
+	}
+	for name, want := range cases {
+		got, err := formatterFor(name)
+		if err != nil {
+			t.Fatalf("formatterFor(%q): %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("formatterFor(%q) = %#v, want %#v", name, got, want)
+		}
+	}
+
+	if _, err := formatterFor("yaml"); err == nil {
+		t.Fatalf("expected an error for an unknown -o value")
+	}
+}
@@ -1,168 +1,134 @@
 package main
 
 import (
-	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
 )
 
-// Node represents a node of a tree of files.
-type Node interface {
-	fmt.Stringer
-}
-
-// DirInfo represents the name and contents of a directory.
-type DirInfo struct {
-	name     string
-	children []Node
-}
-
-// FileInfo represents the name and the size of a file.
-type FileInfo struct {
-	name string
-	size int64
-}
-
-// Join concatenates the elements to create a single string.
-func concatStrings(strings ...string) (string, error) {
-	var buf bytes.Buffer
+func dirTree(out io.Writer, fsys fs.FS, name string, opts Options, formatter Formatter) error {
+	var dirCount, fileCount int
 	var err error
-	for _, item := range strings {
-		_, err = buf.WriteString(item)
-		if err != nil {
-			return "", err
-		}
-	}
-	return buf.String(), err
-}
 
-// Return a string presentation of the FileInfo
-// or empty string if any error happened
-func (fileInfo FileInfo) String() string {
-	if fileInfo.size == 0 {
-		result, err := concatStrings(fileInfo.name, " (empty)")
-		if err != nil {
-			return ""
+	if _, ascii := formatter.(ASCIIFormatter); ascii {
+		// The ASCII tree is the tool's primary use case, so it walks the
+		// filesystem directly instead of materializing the whole tree.
+		dirCount, fileCount, err = walkPrintASCII(out, fsys, name, opts)
+	} else {
+		var nodes *[]Node
+		nodes, dirCount, fileCount, err = readDir(fsys, name, &[]Node{}, opts, 1)
+		if err == nil {
+			if nodes == nil {
+				err = errors.New("no nodes for you")
+			} else {
+				err = formatter.Format(out, *nodes)
+			}
 		}
-		return result
 	}
-	result, err := concatStrings(fileInfo.name, " (", strconv.FormatInt(fileInfo.size, 10), "b)")
 	if err != nil {
-		return ""
+		return err
 	}
-	return result
-}
 
-// Return a string presentation of the DirInfo
-func (directory DirInfo) String() string {
-	return directory.name
-}
-
-// Read a directory
-func readDir(path string, nodes *[]Node, withFiles bool) (result *[]Node, err error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if opts.NoReport || isMtreeOutput(formatter) {
+		return nil
 	}
-	files, err := file.Readdir(0)
-	defer func() {
-		cErr := file.Close()
-		if err == nil {
-			err = cErr
-		}
-	}()
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
-
-	for _, info := range files {
-		if !(info.IsDir() || withFiles) {
-			continue
-		}
-
-		var newNode Node
-		if info.IsDir() {
-			children, _ := readDir(filepath.Join(path, info.Name()), &[]Node{}, withFiles)
-			newNode = DirInfo{info.Name(), *children}
-		} else {
-			newNode = FileInfo{info.Name(), info.Size()}
-		}
+	_, err = fmt.Fprintf(out, "\n%d directories, %d files\n", dirCount, fileCount)
+	return err
+}
 
-		*nodes = append(*nodes, newNode)
+// archiveFormatFor resolves the -archive flag's value to an ArchiveFormat.
+// An empty name means the CLI isn't reading an archive at all.
+func archiveFormatFor(name string) (format ArchiveFormat, isArchive bool, err error) {
+	switch name {
+	case "":
+		return 0, false, nil
+	case "tar":
+		return ArchiveTar, true, nil
+	case "zip":
+		return ArchiveZip, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown -archive value %q, want tar|zip", name)
 	}
-
-	return nodes, err
 }
 
-// Print nodes of a tree of files.
-func printDir(out io.Writer, nodes []Node, prefixes []string) error {
-	if len(nodes) == 0 {
-		return nil
+func parseFlags(args []string) (path string, opts Options, formatter Formatter, archive ArchiveFormat, isArchive bool, err error) {
+	opts = defaultOptions()
+
+	var outputFormat, archiveFormat string
+
+	flagSet := flag.NewFlagSet("dirtree", flag.ContinueOnError)
+	flagSet.IntVar(&opts.MaxDepth, "L", 0, "descend only n levels")
+	flagSet.StringVar(&opts.Include, "P", "", "list only files matching the pattern")
+	flagSet.StringVar(&opts.Exclude, "I", "", "do not list files matching the pattern")
+	flagSet.BoolVar(&opts.DirsOnly, "d", false, "list directories only")
+	flagSet.BoolVar(&opts.DirsFirst, "dirsfirst", false, "list directories before files")
+	flagSet.BoolVar(&opts.NoReport, "noreport", false, "omit the file/directory count report")
+	flagSet.BoolVar(&opts.All, "a", false, "list dotfiles too")
+	flagSet.StringVar(&opts.SortBy, "sort", "name", "sort by name|size|mtime|ctime")
+	flagSet.BoolVar(&opts.Reverse, "r", false, "reverse the order of the sort")
+	flagSet.BoolVar(&opts.WithFiles, "f", true, "include files (deprecated, kept for backward compatibility)")
+	flagSet.StringVar(&outputFormat, "o", "ascii", "output format: ascii|json|xml|html|mtree")
+	flagSet.BoolVar(&opts.Manifest, "manifest", false, "record sha256/md5/mode/uid/gid/mtime/type keywords per entry (reads file contents)")
+	flagSet.StringVar(&archiveFormat, "archive", "", "read a tar|zip stream from stdin instead of walking a directory")
+
+	if err := flagSet.Parse(args); err != nil {
+		return "", opts, nil, 0, false, err
 	}
 
-	totalPrefixes, err := concatStrings(prefixes...)
+	archive, isArchive, err = archiveFormatFor(archiveFormat)
 	if err != nil {
-		return err
+		return "", opts, nil, 0, false, err
 	}
 
-	_, err = fmt.Fprintf(out, "%s", totalPrefixes)
-	if err != nil {
-		return err
+	wantArgs := 1
+	if isArchive {
+		wantArgs = 0
 	}
-
-	node := nodes[0]
-
-	if len(nodes) == 1 {
-		_, err = fmt.Fprintf(out, "%s%s\n", "└───", node)
-		if err != nil {
-			return err
-		}
-		if directory, ok := node.(DirInfo); ok {
-			return printDir(out, directory.children, append(prefixes, "\t"))
-		}
-		return nil
+	if flagSet.NArg() != wantArgs {
+		return "", opts, nil, 0, false, errors.New("usage: dirtree <path> [-L n] [-P pattern] [-I pattern] [-d] [-a] [--dirsfirst] [--noreport] [--sort name|size|mtime|ctime] [-r] [-o ascii|json|xml|html|mtree] [-manifest] OR dirtree -archive tar|zip < stream")
 	}
 
-	_, err = fmt.Fprintf(out, "%s%s\n", "├───", node)
-	if err != nil {
-		return err
+	if opts.Manifest && outputFormat != "mtree" {
+		return "", opts, nil, 0, false, fmt.Errorf("-manifest only has an effect with -o mtree, got -o %q", outputFormat)
 	}
-	if directory, ok := node.(DirInfo); ok {
-		err = printDir(out, directory.children, append(prefixes, "│\t"))
-		if err != nil {
-			return err
-		}
+	if outputFormat == "mtree" {
+		opts.Manifest = true
 	}
 
-	return printDir(out, nodes[1:], prefixes)
-}
+	switch opts.SortBy {
+	case "name", "size", "mtime", "ctime":
+	default:
+		return "", opts, nil, 0, false, fmt.Errorf("unknown -sort value %q", opts.SortBy)
+	}
 
-func dirTree(out io.Writer, path string, isPrintFiles bool) error {
-	nodes, err := readDir(path, &[]Node{}, isPrintFiles)
+	formatter, err = formatterFor(outputFormat)
 	if err != nil {
-		return err
-	}
-	if nodes == nil {
-		return errors.New("no nodes for you")
+		return "", opts, nil, 0, false, err
 	}
 
-	return printDir(out, *nodes, []string{})
+	if isArchive {
+		return "", opts, formatter, archive, true, nil
+	}
+	return flagSet.Arg(0), opts, formatter, archive, false, nil
 }
 
 func main() {
 	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+
+	path, opts, formatter, archive, isArchive, err := parseFlags(os.Args[1:])
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if isArchive {
+		err = dirTreeFromArchive(out, os.Stdin, archive, opts, formatter)
+	} else {
+		err = dirTree(out, newOSFS(path), ".", opts, formatter)
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestArchiveFormatForResolvesFlagValues(t *testing.T) {
+	if format, isArchive, err := archiveFormatFor(""); err != nil || isArchive || format != 0 {
+		t.Fatalf("archiveFormatFor(\"\") = %v, %v, %v", format, isArchive, err)
+	}
+	if format, isArchive, err := archiveFormatFor("tar"); err != nil || !isArchive || format != ArchiveTar {
+		t.Fatalf("archiveFormatFor(\"tar\") = %v, %v, %v", format, isArchive, err)
+	}
+	if format, isArchive, err := archiveFormatFor("zip"); err != nil || !isArchive || format != ArchiveZip {
+		t.Fatalf("archiveFormatFor(\"zip\") = %v, %v, %v", format, isArchive, err)
+	}
+	if _, _, err := archiveFormatFor("rar"); err == nil {
+		t.Fatalf("expected an error for an unknown -archive value")
+	}
+}
+
+func TestParseFlagsAppliesDefaultsAndPositionalPath(t *testing.T) {
+	path, opts, formatter, _, isArchive, err := parseFlags([]string{"-L", "2", "-a", "."})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if path != "." || isArchive {
+		t.Fatalf("expected path \".\" and isArchive=false, got path=%q isArchive=%v", path, isArchive)
+	}
+	if opts.MaxDepth != 2 || !opts.All {
+		t.Fatalf("expected -L/-a to set MaxDepth/All, got %+v", opts)
+	}
+	if _, ok := formatter.(ASCIIFormatter); !ok {
+		t.Fatalf("expected the default formatter to be ASCIIFormatter, got %T", formatter)
+	}
+}
+
+func TestParseFlagsRejectsManifestWithoutMtreeFormat(t *testing.T) {
+	if _, _, _, _, _, err := parseFlags([]string{"-manifest", "."}); err == nil {
+		t.Fatalf("expected an error combining -manifest with the default ascii format")
+	}
+}
+
+func TestParseFlagsSetsManifestForMtreeFormat(t *testing.T) {
+	_, opts, formatter, _, _, err := parseFlags([]string{"-o", "mtree", "."})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !opts.Manifest {
+		t.Fatalf("expected -o mtree to imply -manifest")
+	}
+	if _, ok := formatter.(MtreeFormatter); !ok {
+		t.Fatalf("expected MtreeFormatter, got %T", formatter)
+	}
+}
+
+func TestParseFlagsArchiveModeTakesNoPositionalArg(t *testing.T) {
+	_, _, formatter, archive, isArchive, err := parseFlags([]string{"-archive", "tar", "-o", "json"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !isArchive || archive != ArchiveTar {
+		t.Fatalf("expected archive mode with ArchiveTar, got isArchive=%v archive=%v", isArchive, archive)
+	}
+	if _, ok := formatter.(JSONFormatter); !ok {
+		t.Fatalf("expected JSONFormatter, got %T", formatter)
+	}
+}
+
+func TestDirTreeDispatchesToFormatterAndReportsCounts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b.txt": {Data: []byte("hi")},
+	}
+
+	var buf bytes.Buffer
+	if err := dirTree(&buf, fsys, ".", defaultOptions(), JSONFormatter{}); err != nil {
+		t.Fatalf("dirTree: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `"name": "a"`) {
+		t.Fatalf("expected the JSONFormatter path to run, got:\n%s", out)
+	}
+	if !strings.Contains(out, "1 directories, 1 files") {
+		t.Fatalf("expected the report footer, got:\n%s", out)
+	}
+}
+
+func TestDirTreeASCIIPathOmitsReportWithNoReport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("hi")},
+	}
+	opts := defaultOptions()
+	opts.NoReport = true
+
+	var buf bytes.Buffer
+	if err := dirTree(&buf, fsys, ".", opts, ASCIIFormatter{}); err != nil {
+		t.Fatalf("dirTree: %v", err)
+	}
+	if strings.Contains(buf.String(), "directories") {
+		t.Fatalf("expected -noreport to suppress the footer, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// osFS wraps os.DirFS to additionally expose Readlink: os.DirFS's fs.FS
+// doesn't carry the real root path computeKeywords needs to resolve a
+// symlink's target via os.Readlink, so plain os.DirFS(root) can never
+// satisfy the Readlink interface computeKeywords looks for.
+type osFS struct {
+	fs.FS
+	root string
+}
+
+// newOSFS binds root as the CLI's real-filesystem backend, the same way
+// os.DirFS(root) would, but with working symlink-target resolution.
+func newOSFS(root string) osFS {
+	return osFS{FS: os.DirFS(root), root: root}
+}
+
+// Readlink resolves name (fs.FS-relative, slash-separated) against root and
+// reads its symlink target.
+func (o osFS) Readlink(name string) (string, error) {
+	return os.Readlink(filepath.Join(o.root, filepath.FromSlash(name)))
+}
+
+// ownerOf extracts uid/gid from info.Sys(), which os.DirFS populates with a
+// *syscall.Stat_t on Unix. It reports false for fs.FS backends (e.g.
+// fstest.MapFS) that don't carry OS-level ownership.
+func ownerOf(info fs.FileInfo) (uid, gid string, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), true
+}
+
+// computeKeywords records the mtree-style keyword/value pairs for the entry
+// at name: mode, mtime and type always, uid and gid when info.Sys() carries
+// ownership (see ownerOf), sha256/md5 for regular files, and link for
+// symlinks when fsys can resolve their target. It is only called when Options.Manifest is
+// set, since hashing a file means reading its whole contents.
+func computeKeywords(fsys fs.FS, name string, info fs.FileInfo) (map[string]string, error) {
+	keywords := map[string]string{
+		"mode":  strconv.FormatUint(uint64(info.Mode().Perm()), 8),
+		"mtime": strconv.FormatInt(info.ModTime().Unix(), 10),
+	}
+
+	if uid, gid, ok := ownerOf(info); ok {
+		keywords["uid"] = uid
+		keywords["gid"] = gid
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		keywords["type"] = "link"
+		// fs.FS has no portable symlink-target API; only an fs.FS that
+		// also exposes Readlink (e.g. the real OS filesystem) can fill
+		// this in, so the "link" keyword is left unset otherwise.
+		if linker, ok := fsys.(interface {
+			Readlink(name string) (string, error)
+		}); ok {
+			target, err := linker.Readlink(name)
+			if err != nil {
+				return nil, err
+			}
+			keywords["link"] = target
+		}
+	case info.IsDir():
+		keywords["type"] = "dir"
+	default:
+		keywords["type"] = "file"
+		sum256, sumMD5, err := hashFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		keywords["sha256"] = sum256
+		keywords["md5"] = sumMD5
+	}
+
+	return keywords, nil
+}
+
+// hashFile returns the sha256 and md5 digests of fsys's name, hex encoded,
+// reading it exactly once.
+func hashFile(fsys fs.FS, name string) (sha256Sum, md5Sum string, err error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		cErr := file.Close()
+		if err == nil {
+			err = cErr
+		}
+	}()
+
+	sha := sha256.New()
+	md := md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, md), file); err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("%x", sha.Sum(nil)), fmt.Sprintf("%x", md.Sum(nil)), nil
+}
+
+// manifestKeywordOrder fixes the column order of a manifest line so output
+// is stable and diffable across runs.
+var manifestKeywordOrder = []string{"type", "mode", "uid", "gid", "mtime", "sha256", "md5", "link"}
+
+// manifestEscapes maps the bytes a manifest line can't carry unescaped -
+// whitespace would otherwise be read as a field separator by strings.Fields,
+// and a literal backslash would be ambiguous with the escape itself - to
+// their mtree-style octal escape.
+var manifestEscapes = map[byte]string{
+	' ':  `\040`,
+	'\t': `\011`,
+	'\n': `\012`,
+	'\\': `\134`,
+}
+
+// escapeManifestField escapes s so it survives as a single whitespace-delimited
+// field in a manifest line, matching real mtree's quoting of unsafe characters.
+func escapeManifestField(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if escape, ok := manifestEscapes[s[i]]; ok {
+			buf.WriteString(escape)
+			continue
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// unescapeManifestField reverses escapeManifestField.
+func unescapeManifestField(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if code, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				buf.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// writeManifestLine writes one mtree-style "path keyword=value ..." line.
+// The path and the link= target are the only free-form values a manifest
+// carries, so they're the only fields that need escaping.
+func writeManifestLine(out io.Writer, name string, keywords map[string]string) error {
+	var line strings.Builder
+	line.WriteString(escapeManifestField(name))
+	for _, keyword := range manifestKeywordOrder {
+		value, ok := keywords[keyword]
+		if !ok {
+			continue
+		}
+		if keyword == "link" {
+			value = escapeManifestField(value)
+		}
+		line.WriteByte(' ')
+		line.WriteString(keyword)
+		line.WriteByte('=')
+		line.WriteString(value)
+	}
+	_, err := fmt.Fprintln(out, line.String())
+	return err
+}
+
+func writeManifestNodes(out io.Writer, nodes []Node, prefix string) error {
+	for _, node := range nodes {
+		relPath := node.Name()
+		if prefix != "" {
+			relPath = prefix + "/" + relPath
+		}
+
+		if directory, ok := node.(DirInfo); ok {
+			if keywords := directory.Keywords(); keywords != nil {
+				if err := writeManifestLine(out, relPath, keywords); err != nil {
+					return err
+				}
+			}
+			if err := writeManifestNodes(out, directory.Children(), relPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file := node.(FileInfo)
+		if keywords := file.Keywords(); keywords != nil {
+			if err := writeManifestLine(out, relPath, keywords); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MtreeFormatter renders a manifest built with Options.Manifest as an
+// mtree-style textual listing, one "path keyword=value ..." line per entry.
+type MtreeFormatter struct{}
+
+// Format writes nodes' recorded keywords in mtree format. Nodes built
+// without Options.Manifest set carry no keywords and are skipped.
+func (MtreeFormatter) Format(out io.Writer, nodes []Node) error {
+	return writeManifestNodes(out, nodes, "")
+}
+
+// Failure describes a single keyword that didn't match between a manifest
+// and the tree it was verified against.
+type Failure struct {
+	Path     string
+	Keyword  string
+	Expected string
+	Got      string
+}
+
+// Verify looks up each manifest entry in fsys, recomputes its keywords, and
+// returns every mismatch it finds. Entries present in fsys but not listed in
+// the manifest are not reported. manifestReader must contain lines
+// produced by MtreeFormatter.
+func Verify(fsys fs.FS, manifestReader io.Reader) ([]Failure, error) {
+	var failures []Failure
+
+	scanner := bufio.NewScanner(manifestReader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		relPath, expected := unescapeManifestField(fields[0]), fields[1:]
+		entryPath := path.Clean(relPath)
+
+		info, err := fs.Stat(fsys, entryPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			failures = append(failures, Failure{Path: relPath, Keyword: "exists", Expected: "present", Got: "missing"})
+			continue
+		}
+		if err != nil {
+			return failures, err
+		}
+
+		actual, err := computeKeywords(fsys, entryPath, info)
+		if err != nil {
+			return failures, err
+		}
+
+		for _, pair := range expected {
+			keyword, value, found := strings.Cut(pair, "=")
+			if !found {
+				continue
+			}
+			if keyword == "link" {
+				value = unescapeManifestField(value)
+			}
+			if got := actual[keyword]; got != value {
+				failures = append(failures, Failure{Path: relPath, Keyword: keyword, Expected: value, Got: got})
+			}
+		}
+	}
+
+	return failures, scanner.Err()
+}
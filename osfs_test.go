@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestOSFSRecordsSymlinkTarget(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(root+"/target.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Symlink("target.txt", root+"/link.txt"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	opts := defaultOptions()
+	opts.Manifest = true
+
+	var buf bytes.Buffer
+	if err := dirTree(&buf, newOSFS(root), ".", opts, MtreeFormatter{}); err != nil {
+		t.Fatalf("dirTree: %v", err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte("link=target.txt")) {
+		t.Fatalf("expected manifest to record the symlink target, got:\n%s", buf.String())
+	}
+}
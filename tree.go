@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node represents a node of a tree of files.
+type Node interface {
+	fmt.Stringer
+	Name() string
+	IsDir() bool
+}
+
+// DirInfo represents the name and contents of a directory.
+type DirInfo struct {
+	name     string
+	children []Node
+	keywords map[string]string // set only in manifest mode; see Options.Manifest
+}
+
+// FileInfo represents the name and the size of a file.
+type FileInfo struct {
+	name     string
+	size     int64
+	keywords map[string]string // set only in manifest mode; see Options.Manifest
+}
+
+// Options controls how the tree is traversed and rendered, mirroring the
+// flags of the Unix tree(1) command.
+type Options struct {
+	WithFiles bool   // print files as well as directories
+	MaxDepth  int    // -L: descend only this many levels (0 = unlimited)
+	Include   string // -P: list only files matching this pattern
+	Exclude   string // -I: do not list files matching this pattern
+	DirsOnly  bool   // -d: list directories only
+	DirsFirst bool   // --dirsfirst: list directories before files
+	NoReport  bool   // --noreport: omit the trailing "N directories, M files" line
+	All       bool   // -a: list dotfiles too
+	SortBy    string // --sort: name|size|mtime|ctime
+	Reverse   bool   // -r: reverse the sort order
+	Manifest  bool   // -manifest: record sha256/md5/mode/uid/gid/mtime/type keywords (reads file contents; off by default)
+}
+
+// defaultOptions returns the Options tree(1) itself defaults to.
+func defaultOptions() Options {
+	return Options{
+		WithFiles: true,
+		SortBy:    "name",
+	}
+}
+
+// concatStrings concatenates the elements to create a single string.
+func concatStrings(strings ...string) (string, error) {
+	var buf bytes.Buffer
+	var err error
+	for _, item := range strings {
+		_, err = buf.WriteString(item)
+		if err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), err
+}
+
+// Name returns the file's name.
+func (fileInfo FileInfo) Name() string {
+	return fileInfo.name
+}
+
+// IsDir reports that a FileInfo is never a directory.
+func (fileInfo FileInfo) IsDir() bool {
+	return false
+}
+
+// Size returns the file's size in bytes.
+func (fileInfo FileInfo) Size() int64 {
+	return fileInfo.size
+}
+
+// Keywords returns the mtree-style keywords recorded for this file in
+// manifest mode, or nil if manifest mode was off.
+func (fileInfo FileInfo) Keywords() map[string]string {
+	return fileInfo.keywords
+}
+
+// Return a string presentation of the FileInfo
+// or empty string if any error happened
+func (fileInfo FileInfo) String() string {
+	if fileInfo.size == 0 {
+		result, err := concatStrings(fileInfo.name, " (empty)")
+		if err != nil {
+			return ""
+		}
+		return result
+	}
+	result, err := concatStrings(fileInfo.name, " (", strconv.FormatInt(fileInfo.size, 10), "b)")
+	if err != nil {
+		return ""
+	}
+	return result
+}
+
+// Name returns the directory's name.
+func (directory DirInfo) Name() string {
+	return directory.name
+}
+
+// IsDir reports that a DirInfo is always a directory.
+func (directory DirInfo) IsDir() bool {
+	return true
+}
+
+// Children returns the directory's immediate contents.
+func (directory DirInfo) Children() []Node {
+	return directory.children
+}
+
+// Keywords returns the mtree-style keywords recorded for this directory in
+// manifest mode, or nil if manifest mode was off.
+func (directory DirInfo) Keywords() map[string]string {
+	return directory.keywords
+}
+
+// Return a string presentation of the DirInfo
+func (directory DirInfo) String() string {
+	return directory.name
+}
+
+// matchesPattern reports whether name matches the given shell glob pattern.
+// An empty pattern always matches.
+func matchesPattern(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		return false
+	}
+	return matched
+}
+
+// sortEntries orders files in place according to opts.SortBy and opts.Reverse.
+func sortEntries(files []fs.FileInfo, opts Options) {
+	less := func(i, j int) bool {
+		switch opts.SortBy {
+		case "size":
+			return files[i].Size() < files[j].Size()
+		case "mtime", "ctime":
+			return files[i].ModTime().Before(files[j].ModTime())
+		default:
+			return files[i].Name() < files[j].Name()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if opts.Reverse {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+	if opts.DirsFirst {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].IsDir() && !files[j].IsDir()
+		})
+	}
+}
+
+// readDirBatched lists name's entries from fsys, reading them in batches of
+// 1024 via repeated ReadDir(n) calls on the underlying fs.ReadDirFile rather
+// than in one fs.ReadDir call. Every batch is appended to the returned slice,
+// so the directory's whole listing is still held in memory at once; batching
+// only bounds the size of each individual read. It falls back to a single
+// fs.ReadDir for fs.FS implementations that don't support batching.
+func readDirBatched(fsys fs.FS, name string) (files []fs.FileInfo, err error) {
+	const batchSize = 1024
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		cErr := file.Close()
+		if err == nil {
+			err = cErr
+		}
+	}()
+
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return entryInfos(entries)
+	}
+
+	for {
+		batch, berr := dir.ReadDir(batchSize)
+		infos, ierr := entryInfos(batch)
+		if ierr != nil {
+			return files, ierr
+		}
+		files = append(files, infos...)
+		if berr == io.EOF {
+			break
+		}
+		if berr != nil {
+			return files, berr
+		}
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	return files, nil
+}
+
+// entryInfos resolves a batch of fs.DirEntry to fs.FileInfo.
+func entryInfos(entries []fs.DirEntry) ([]fs.FileInfo, error) {
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// filterEntries keeps the entries of files that survive opts' dotfile,
+// directories-only, and include/exclude pattern filters.
+func filterEntries(files []fs.FileInfo, opts Options) []fs.FileInfo {
+	kept := files[:0]
+	for _, info := range files {
+		name := info.Name()
+		if !opts.All && strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if info.IsDir() {
+			if opts.Exclude != "" && matchesPattern(opts.Exclude, name) {
+				continue
+			}
+		} else {
+			if !opts.WithFiles || opts.DirsOnly {
+				continue
+			}
+			if opts.Exclude != "" && matchesPattern(opts.Exclude, name) {
+				continue
+			}
+			if opts.Include != "" && !matchesPattern(opts.Include, name) {
+				continue
+			}
+		}
+
+		kept = append(kept, info)
+	}
+	return kept
+}
+
+// Read a directory out of fsys, applying opts filters, and reporting how
+// many directories and files were kept so dirTree can print its summary
+// line. name is fs.FS-relative (slash-separated, "." for fsys's root).
+func readDir(fsys fs.FS, name string, nodes *[]Node, opts Options, depth int) (result *[]Node, dirCount int, fileCount int, err error) {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return nodes, 0, 0, nil
+	}
+
+	files, err := readDirBatched(fsys, name)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	files = filterEntries(files, opts)
+	sortEntries(files, opts)
+
+	for _, info := range files {
+		entryPath := path.Join(name, info.Name())
+
+		var keywords map[string]string
+		if opts.Manifest {
+			keywords, err = computeKeywords(fsys, entryPath, info)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+		}
+
+		var newNode Node
+		if info.IsDir() {
+			children, childDirs, childFiles, _ := readDir(fsys, entryPath, &[]Node{}, opts, depth+1)
+			newNode = DirInfo{name: info.Name(), children: *children, keywords: keywords}
+			dirCount += 1 + childDirs
+			fileCount += childFiles
+		} else {
+			newNode = FileInfo{name: info.Name(), size: info.Size(), keywords: keywords}
+			fileCount++
+		}
+
+		*nodes = append(*nodes, newNode)
+	}
+
+	return nodes, dirCount, fileCount, err
+}
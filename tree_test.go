@@ -0,0 +1,182 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func sampleFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a/b.txt":     {Data: []byte("hi")},
+		"a/.hidden":   {Data: []byte("x")},
+		"a/sub/c.txt": {Data: []byte("hello")},
+		"top.txt":     {Data: []byte("y")},
+	}
+}
+
+func collectNames(nodes []Node) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name())
+		if directory, ok := node.(DirInfo); ok {
+			names = append(names, collectNames(directory.Children())...)
+		}
+	}
+	return names
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReadDirHidesDotfilesByDefault(t *testing.T) {
+	opts := defaultOptions()
+
+	nodes, _, _, err := readDir(sampleFS(), ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+
+	if names := collectNames(*nodes); containsName(names, ".hidden") {
+		t.Fatalf("expected .hidden to be filtered out, got %v", names)
+	}
+}
+
+func TestReadDirAllShowsDotfiles(t *testing.T) {
+	opts := defaultOptions()
+	opts.All = true
+
+	nodes, _, _, err := readDir(sampleFS(), ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+
+	if names := collectNames(*nodes); !containsName(names, ".hidden") {
+		t.Fatalf("expected .hidden with -a, got %v", names)
+	}
+}
+
+func TestReadDirMaxDepthStopsDescending(t *testing.T) {
+	opts := defaultOptions()
+	opts.MaxDepth = 1
+
+	nodes, dirCount, fileCount, err := readDir(sampleFS(), ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+
+	if dirCount != 1 || fileCount != 1 {
+		t.Fatalf("expected 1 directory and 1 file at depth 1, got dirs=%d files=%d", dirCount, fileCount)
+	}
+
+	for _, node := range *nodes {
+		if directory, ok := node.(DirInfo); ok && len(directory.Children()) != 0 {
+			t.Fatalf("expected %q to have no children beyond max depth, got %v", directory.Name(), directory.Children())
+		}
+	}
+}
+
+func TestReadDirIncludeExcludePatterns(t *testing.T) {
+	opts := defaultOptions()
+	opts.Include = "*.txt"
+	opts.Exclude = "b.txt"
+
+	nodes, _, _, err := readDir(sampleFS(), ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+
+	names := collectNames(*nodes)
+	if containsName(names, "b.txt") {
+		t.Fatalf("expected b.txt to be excluded, got %v", names)
+	}
+	if !containsName(names, "c.txt") {
+		t.Fatalf("expected c.txt to survive the include pattern, got %v", names)
+	}
+}
+
+func TestReadDirDirsOnly(t *testing.T) {
+	opts := defaultOptions()
+	opts.DirsOnly = true
+
+	nodes, _, fileCount, err := readDir(sampleFS(), ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+	if fileCount != 0 {
+		t.Fatalf("expected no files with -d, got fileCount=%d", fileCount)
+	}
+	for _, node := range *nodes {
+		if !node.IsDir() {
+			t.Fatalf("expected only directories at the top level, got %v", node.Name())
+		}
+	}
+}
+
+func TestReadDirSortBySizeReverse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"small.txt": {Data: []byte("a")},
+		"big.txt":   {Data: []byte("aaaaaaaaaa")},
+	}
+	opts := defaultOptions()
+	opts.SortBy = "size"
+	opts.Reverse = true
+
+	nodes, _, _, err := readDir(fsys, ".", &[]Node{}, opts, 1)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+	if len(*nodes) != 2 || (*nodes)[0].Name() != "big.txt" || (*nodes)[1].Name() != "small.txt" {
+		t.Fatalf("expected big.txt before small.txt in reverse size order, got %v", collectNames(*nodes))
+	}
+}
+
+func TestWalkEmitsMatchingEnterLeavePairs(t *testing.T) {
+	opts := defaultOptions()
+
+	var depth int
+	err := Walk(sampleFS(), ".", opts, func(event Event) error {
+		switch event.Kind {
+		case Enter:
+			if event.IsDir {
+				depth++
+			}
+		case Leave:
+			depth--
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if depth != 0 {
+		t.Fatalf("expected every Enter for a directory to have a matching Leave, depth ended at %d", depth)
+	}
+}
+
+func TestWalkMarksLastSibling(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("a")},
+		"b.txt": {Data: []byte("b")},
+	}
+	opts := defaultOptions()
+
+	var lastNames []string
+	err := Walk(fsys, ".", opts, func(event Event) error {
+		if event.Kind == Enter && event.Last {
+			lastNames = append(lastNames, event.Info.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(lastNames) != 1 || lastNames[0] != "b.txt" {
+		t.Fatalf("expected only b.txt to be marked Last, got %v", lastNames)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// EventKind distinguishes entering a node from leaving a directory once all
+// of its children have been visited.
+type EventKind int
+
+const (
+	// Enter fires once per node (file or directory), before its children.
+	Enter EventKind = iota
+	// Leave fires for a directory after all of its children were visited.
+	Leave
+)
+
+// Event describes one step of a Walk: entering a file or directory, or
+// leaving a directory whose children are done.
+type Event struct {
+	Depth int
+	IsDir bool
+	Kind  EventKind
+	Info  Node
+	// Last reports whether Info is the final surviving sibling at Depth,
+	// once opts' filters have been applied - renderers need it to choose
+	// between a "├───" and a "└───" connector.
+	Last bool
+}
+
+// Walk traverses name (fs.FS-relative, "." for fsys's root) depth-first,
+// applying opts' filters and sort order, and calls visit for every
+// Enter/Leave event. Unlike readDir it never materializes the whole tree:
+// only the entries of directories on the current path are held in memory
+// at once, so memory use grows with the depth of the tree and the size of
+// those directories, not with the total number of nodes. Each of those
+// directories' listings is held in full.
+func Walk(fsys fs.FS, name string, opts Options, visit func(Event) error) error {
+	return walk(fsys, name, opts, 1, visit)
+}
+
+func walk(fsys fs.FS, name string, opts Options, depth int, visit func(Event) error) error {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return nil
+	}
+
+	files, err := readDirBatched(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	files = filterEntries(files, opts)
+	sortEntries(files, opts)
+
+	for i, info := range files {
+		last := i == len(files)-1
+
+		var node Node
+		if info.IsDir() {
+			node = DirInfo{name: info.Name()}
+		} else {
+			node = FileInfo{name: info.Name(), size: info.Size()}
+		}
+
+		if err := visit(Event{Depth: depth, IsDir: info.IsDir(), Kind: Enter, Info: node, Last: last}); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if err := walk(fsys, path.Join(name, info.Name()), opts, depth+1, visit); err != nil {
+				return err
+			}
+			if err := visit(Event{Depth: depth, IsDir: true, Kind: Leave, Info: node, Last: last}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// nodeWalk traverses a materialized []Node tree (e.g. one built by readDir
+// or the archive reader) depth-first, emitting the same Enter/Leave Events
+// Walk produces for a live fs.FS, so renderASCII can draw both off a single
+// implementation.
+func nodeWalk(nodes []Node, visit func(Event) error) error {
+	return nodeWalkAt(nodes, 1, visit)
+}
+
+func nodeWalkAt(nodes []Node, depth int, visit func(Event) error) error {
+	for i, node := range nodes {
+		last := i == len(nodes)-1
+
+		if err := visit(Event{Depth: depth, IsDir: node.IsDir(), Kind: Enter, Info: node, Last: last}); err != nil {
+			return err
+		}
+
+		if directory, ok := node.(DirInfo); ok {
+			if err := nodeWalkAt(directory.Children(), depth+1, visit); err != nil {
+				return err
+			}
+			if err := visit(Event{Depth: depth, IsDir: true, Kind: Leave, Info: node, Last: last}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// renderASCII draws an Enter/Leave Event stream as the box-drawing ASCII
+// tree this tool has always printed, and returns the directory/file counts
+// dirTree needs for its report line. forEach is either Walk over a live
+// fs.FS or nodeWalk over an already-materialized []Node tree: either way the
+// connector/prefix logic only needs to exist once.
+func renderASCII(out io.Writer, forEach func(visit func(Event) error) error) (dirCount int, fileCount int, err error) {
+	var prefixes []string
+
+	err = forEach(func(event Event) error {
+		if event.Kind == Leave {
+			prefixes = prefixes[:len(prefixes)-1]
+			return nil
+		}
+
+		connector := "├───"
+		if event.Last {
+			connector = "└───"
+		}
+
+		if event.IsDir {
+			dirCount++
+		} else {
+			fileCount++
+		}
+
+		totalPrefixes, err := concatStrings(prefixes...)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "%s%s%s\n", totalPrefixes, connector, event.Info); err != nil {
+			return err
+		}
+
+		if event.IsDir {
+			if event.Last {
+				prefixes = append(prefixes, "\t")
+			} else {
+				prefixes = append(prefixes, "│\t")
+			}
+		}
+
+		return nil
+	})
+
+	return dirCount, fileCount, err
+}
+
+// walkPrintASCII renders fsys's name as an ASCII tree directly off Walk,
+// without ever materializing the whole tree in memory.
+func walkPrintASCII(out io.Writer, fsys fs.FS, name string, opts Options) (dirCount int, fileCount int, err error) {
+	return renderASCII(out, func(visit func(Event) error) error {
+		return Walk(fsys, name, opts, visit)
+	})
+}